@@ -0,0 +1,135 @@
+package gateway_controller
+
+import (
+	"fmt"
+
+	"github.com/argoproj/argo-events/common"
+	client "github.com/argoproj/argo-events/pkg/gateway-client/clientset/versioned/typed/gateway/v1alpha1"
+	"github.com/argoproj/argo-events/pkg/apis/gateway/v1alpha1"
+	zlog "github.com/rs/zerolog"
+	appv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/tools/cache"
+	"os"
+)
+
+// GatewayClassController watches GatewayClass objects and makes their pod/service templates
+// available to gwOperationCtx.operate() so gateways referencing a class don't have to
+// hard-code container spec generation themselves: a class carries the shared template (image,
+// ports, service account, sidecar overrides), and a gateway that references it merges its own
+// per-gateway overrides on top via renderFromClass/mergeDeploySpec.
+type GatewayClassController struct {
+	// gatewayClientset is used to list/watch GatewayClass objects
+	gatewayClientset client.ArgoprojV1alpha1Interface
+	// informer indexes known GatewayClass objects by name
+	informer cache.SharedIndexInformer
+	// log is the logger for the class controller
+	log zlog.Logger
+}
+
+// NewGatewayClassController creates a GatewayClassController and wires up its informer.
+func NewGatewayClassController(gatewayClientset client.ArgoprojV1alpha1Interface, informer cache.SharedIndexInformer) *GatewayClassController {
+	return &GatewayClassController{
+		gatewayClientset: gatewayClientset,
+		informer:         informer,
+		log:              zlog.New(os.Stdout).With().Str("component", "gateway-class-controller").Logger(),
+	}
+}
+
+// GetClass looks up a GatewayClass by name from the informer cache.
+func (gcc *GatewayClassController) GetClass(name string) (*v1alpha1.GatewayClass, error) {
+	obj, exists, err := gcc.informer.GetStore().GetByKey(name)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("gateway class '%s' not found", name)
+	}
+	class, ok := obj.(*v1alpha1.GatewayClass)
+	if !ok {
+		return nil, fmt.Errorf("object with key '%s' is not a GatewayClass", name)
+	}
+	return class, nil
+}
+
+// renderFromClass renders the pod and service templates declared on a GatewayClass,
+// substituting per-gateway values (name, namespace, ports, service account, image overrides)
+// and merging any DeploySpec the gateway supplies on top of the class defaults.
+func (goc *gwOperationCtx) renderFromClass(class *v1alpha1.GatewayClass) (*appv1.Deployment, *corev1.Service) {
+	deployment := class.Spec.Template.Deployment.DeepCopy()
+	deployment.ObjectMeta.Name = common.DefaultGatewayDeploymentName(goc.gw.Name)
+	deployment.ObjectMeta.Namespace = goc.gw.Namespace
+	deployment.ObjectMeta.Labels = map[string]string{
+		common.LabelGatewayName: goc.gw.Name,
+	}
+	deployment.ObjectMeta.OwnerReferences = []metav1.OwnerReference{
+		*metav1.NewControllerRef(goc.gw, v1alpha1.SchemaGroupVersionKind),
+	}
+	deployment.Spec.Selector = &metav1.LabelSelector{
+		MatchLabels: map[string]string{
+			common.LabelGatewayName: goc.gw.Name,
+		},
+	}
+	deployment.Spec.Template.ObjectMeta.Labels = map[string]string{
+		common.LabelGatewayName: goc.gw.Name,
+	}
+	deployment.Spec.Template.Spec.ServiceAccountName = goc.gw.Spec.ServiceAccountName
+
+	// per-gateway overrides take precedence over the class template.
+	if goc.gw.Spec.DeploySpec != nil {
+		mergeDeploySpec(&deployment.Spec.Template.Spec, goc.gw.Spec.DeploySpec)
+	}
+
+	var service *corev1.Service
+	if class.Spec.Template.Service != nil {
+		service = class.Spec.Template.Service.DeepCopy()
+		service.ObjectMeta.Name = common.DefaultGatewayServiceName(goc.gw.Name)
+		service.ObjectMeta.Namespace = goc.gw.Namespace
+		service.ObjectMeta.OwnerReferences = []metav1.OwnerReference{
+			*metav1.NewControllerRef(goc.gw, v1alpha1.SchemaGroupVersionKind),
+		}
+		service.Spec.Selector = map[string]string{
+			common.LabelGatewayName: goc.gw.Name,
+		}
+		// a port set on the gateway replaces the class template's service ports.
+		if goc.gw.Spec.Service.Port != 0 {
+			service.Spec.Ports = []corev1.ServicePort{
+				{
+					Port:       goc.gw.Spec.Service.Port,
+					TargetPort: intstr.FromInt(goc.gw.Spec.Service.TargetPort),
+				},
+			}
+		}
+	}
+	return deployment, service
+}
+
+// mergeDeploySpec overlays user-provided container overrides (e.g. swapping the transformer
+// sidecar image) onto the pod spec rendered from the GatewayClass template. An override whose
+// Name doesn't match any container already in base is appended rather than dropped, so a
+// gateway referencing a class can also add a container the class template doesn't define (e.g.
+// an extra sidecar) instead of only being able to tweak the class's existing ones.
+func mergeDeploySpec(base *corev1.PodSpec, override *corev1.PodSpec) {
+	for _, overrideContainer := range override.Containers {
+		matched := false
+		for i, baseContainer := range base.Containers {
+			if baseContainer.Name == overrideContainer.Name {
+				matched = true
+				if overrideContainer.Image != "" {
+					base.Containers[i].Image = overrideContainer.Image
+				}
+				if overrideContainer.ImagePullPolicy != "" {
+					base.Containers[i].ImagePullPolicy = overrideContainer.ImagePullPolicy
+				}
+				if len(overrideContainer.Env) > 0 {
+					base.Containers[i].Env = append(base.Containers[i].Env, overrideContainer.Env...)
+				}
+			}
+		}
+		if !matched {
+			base.Containers = append(base.Containers, overrideContainer)
+		}
+	}
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/argoproj/argo-events/common"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/apimachinery/pkg/util/wait"
+	"reflect"
 	"strings"
 )
 
@@ -27,6 +28,11 @@ type gwOperationCtx struct {
 	log zlog.Logger
 	// reference to the gateway-controller-controller
 	controller *GatewayController
+	// resolvedSensors is the subset of gw.Spec.Sensors this gateway is actually permitted to
+	// route to, populated by validate()'s resolveSensorRefs: every same-namespace sensor, plus
+	// any cross-namespace sensor covered by a SensorReferenceGrant. Sensors left out are not
+	// programmed into the transformer ConfigMap.
+	resolvedSensors []string
 }
 
 // newGatewayOperationCtx creates and initializes a new gOperationCtx object
@@ -39,186 +45,243 @@ func newGatewayOperationCtx(gw *v1alpha1.Gateway, controller *GatewayController)
 	}
 }
 
+// operate reconciles a gateway towards its desired state. Unlike a one-shot state machine,
+// this runs on every invocation regardless of the gateway's current conditions: it computes the
+// desired ConfigMap/Deployment/Service from goc.gw.Spec, diffs them against the live cluster
+// state and applies whatever update is required. This means a spec edit is always picked up,
+// not just the transition out of an initial phase, and status.observedGeneration lets clients
+// tell when the controller has caught up with the latest spec.
 func (goc *gwOperationCtx) operate() error {
 	goc.log.Info().Msg("started operating on the gateway")
+	gatewayClient := goc.controller.gatewayClientset.ArgoprojV1alpha1().Gateways(goc.gw.Namespace)
+
 	// validate the gateway
-	err := goc.validate()
-	if err != nil {
+	if err := goc.validate(); err != nil {
 		goc.log.Error().Err(err).Msg("gateway validation failed")
+		goc.setCondition(v1alpha1.GatewayConditionAccepted, corev1.ConditionFalse, "InvalidSpec", err.Error())
+		goc.setCondition(v1alpha1.GatewayConditionReady, corev1.ConditionFalse, "InvalidSpec", err.Error())
+		goc.persistStatus(gatewayClient)
 		return err
 	}
-	gatewayClient := goc.controller.gatewayClientset.ArgoprojV1alpha1().Gateways(goc.gw.Namespace)
+	goc.setCondition(v1alpha1.GatewayConditionAccepted, corev1.ConditionTrue, "Valid", "gateway spec is valid")
 
-	// manages states of a gateway
-	switch goc.gw.Status {
-	case v1alpha1.NodePhaseNew:
-		// Update node phase to running
-		goc.gw.Status = v1alpha1.NodePhaseRunning
+	if err := goc.reconcileConfigMap(); err != nil {
+		goc.log.Error().Err(err).Msg("failed to reconcile transformer configmap")
+		goc.setCondition(v1alpha1.GatewayConditionTransformerReady, corev1.ConditionFalse, "ConfigMapReconcileFailed", err.Error())
+		goc.setCondition(v1alpha1.GatewayConditionReady, corev1.ConditionFalse, "ConfigMapReconcileFailed", err.Error())
+		goc.persistStatus(gatewayClient)
+		return err
+	}
+	goc.setCondition(v1alpha1.GatewayConditionTransformerReady, corev1.ConditionTrue, "ConfigMapReconciled", "transformer configuration is up to date")
 
-		// declare the configuration map for gateway transformer
-		gatewayConfigMap := &corev1.ConfigMap{
-			ObjectMeta: metav1.ObjectMeta{
-				Name:      common.DefaultGatewayTransformerConfigMapName(goc.gw.Name),
-				Namespace: goc.gw.Namespace,
-				OwnerReferences: []metav1.OwnerReference{
-					*metav1.NewControllerRef(goc.gw, v1alpha1.SchemaGroupVersionKind),
-				},
-			},
-			Data: map[string]string{
-				common.EventSource:      goc.gw.Name,
-				common.EventTypeVersion: goc.gw.Spec.Version,
-				common.EventType:        goc.gw.Spec.Type,
-				common.SensorList:       strings.Join(goc.gw.Spec.Sensors, ","),
+	if err := goc.reconcileDeploymentAndService(); err != nil {
+		goc.log.Error().Err(err).Msg("failed to reconcile gateway deployment")
+		goc.setCondition(v1alpha1.GatewayConditionProgrammed, corev1.ConditionFalse, "DeploymentReconcileFailed", err.Error())
+		goc.setCondition(v1alpha1.GatewayConditionReady, corev1.ConditionFalse, "DeploymentReconcileFailed", err.Error())
+		goc.persistStatus(gatewayClient)
+		return err
+	}
+	goc.setCondition(v1alpha1.GatewayConditionProgrammed, corev1.ConditionTrue, "DeploymentReconciled", "gateway deployment matches the desired spec")
+	goc.setCondition(v1alpha1.GatewayConditionReady, corev1.ConditionTrue, "Reconciled", "gateway has been reconciled")
+
+	return goc.commitStatus(gatewayClient)
+}
+
+// reconcileConfigMap computes the desired transformer ConfigMap from goc.gw.Spec and creates or
+// updates it to match, rather than only creating it once on the initial transition.
+func (goc *gwOperationCtx) reconcileConfigMap() error {
+	data := map[string]string{
+		common.EventSource:       goc.gw.Name,
+		common.EventTypeVersion:  goc.gw.Spec.Version,
+		common.EventType:         goc.gw.Spec.Type,
+		common.DispatchMechanism: string(goc.gw.Spec.DispatchMechanism),
+		common.SensorList:        strings.Join(goc.resolvedSensors, ","),
+	}
+	for key, value := range goc.dispatchConfigMapData() {
+		data[key] = value
+	}
+
+	desired := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      common.DefaultGatewayTransformerConfigMapName(goc.gw.Name),
+			Namespace: goc.gw.Namespace,
+			OwnerReferences: []metav1.OwnerReference{
+				*metav1.NewControllerRef(goc.gw, v1alpha1.SchemaGroupVersionKind),
 			},
-		}
-		// create gateway transformer configmap
-		_, err = goc.controller.kubeClientset.CoreV1().ConfigMaps(goc.gw.Namespace).Create(gatewayConfigMap)
+		},
+		Data: data,
+	}
+
+	configMaps := goc.controller.kubeClientset.CoreV1().ConfigMaps(goc.gw.Namespace)
+	existing, err := configMaps.Get(desired.Name, metav1.GetOptions{})
+	if err != nil {
+		_, err = configMaps.Create(desired)
+		return err
+	}
+	if reflect.DeepEqual(existing.Data, desired.Data) {
+		return nil
+	}
+	existing.Data = desired.Data
+	_, err = configMaps.Update(existing)
+	return err
+}
+
+// dispatchConfigMapData renders goc.gw.Spec.EventBus into the ConfigMap keys
+// dispatchConfigFromConfigMap (transform package) reads back: broker addresses, the credentials
+// secret reference, the partition key/QoS, and a "route-<sensor>" key per entry in
+// EventBus.SensorRoutingKeys. It is a no-op for gateways with no EventBus configured (HTTPGateway).
+func (goc *gwOperationCtx) dispatchConfigMapData() map[string]string {
+	data := make(map[string]string)
+	eventBus := goc.gw.Spec.EventBus
+	if eventBus == nil {
+		return data
+	}
+	if len(eventBus.Addresses) > 0 {
+		data[common.EventBusAddresses] = strings.Join(eventBus.Addresses, ",")
+	}
+	if eventBus.CredentialsSecretName != "" {
+		data[common.EventBusCredentialsSecretName] = eventBus.CredentialsSecretName
+	}
+	if eventBus.CredentialsSecretKey != "" {
+		data[common.EventBusCredentialsSecretKey] = eventBus.CredentialsSecretKey
+	}
+	if eventBus.PartitionKey != "" {
+		data[common.EventBusPartitionKey] = eventBus.PartitionKey
+	}
+	if eventBus.QoS != 0 {
+		data[common.EventBusQoS] = fmt.Sprintf("%d", eventBus.QoS)
+	}
+	for sensor, routingKey := range eventBus.SensorRoutingKeys {
+		data[common.SensorRoutingKeyPrefix+sensor] = routingKey
+	}
+	return data
+}
+
+// reconcileDeploymentAndService computes the desired Deployment (and Service, if configured)
+// and either creates them or updates the live objects so they converge on the desired spec.
+func (goc *gwOperationCtx) reconcileDeploymentAndService() error {
+	if goc.gw.Spec.ImagePullPolicy == "" {
+		goc.gw.Spec.ImagePullPolicy = corev1.PullAlways
+	}
+
+	// if the gateway references a GatewayClass, render its pod/service templates instead of
+	// hard-coding the container spec here. Per-gateway DeploySpec overrides (e.g. swapping
+	// the transformer sidecar image) are merged on top of the class template.
+	var desiredDeployment *appv1.Deployment
+	var desiredService *corev1.Service
+	if goc.gw.Spec.GatewayClassName != "" {
+		class, err := goc.controller.gatewayClassController.GetClass(goc.gw.Spec.GatewayClassName)
 		if err != nil {
-			goc.log.Error().Err(err).Msg("failed to create transformer gateway configuration")
-			// mark gateway as failed
-			goc.gw.Status = v1alpha1.NodePhaseError
-			goc.gw, err = gatewayClient.Update(goc.gw)
-			if err != nil {
-				err = goc.reapplyUpdate(gatewayClient)
-				if err != nil {
-					goc.log.Error().Err(err).Msg("failed to update gateway")
-					return err
-				}
-			}
+			return err
 		}
+		desiredDeployment, desiredService = goc.renderFromClass(class)
+	} else {
+		desiredDeployment = goc.defaultDeployment()
+		if goc.gw.Spec.Service.Port != 0 {
+			desiredService = goc.defaultService()
+		}
+	}
 
-		// set the image policy if not specified
-		if goc.gw.Spec.ImagePullPolicy == "" {
-			goc.gw.Spec.ImagePullPolicy = corev1.PullAlways
+	deployments := goc.controller.kubeClientset.AppsV1().Deployments(goc.gw.Namespace)
+	existing, err := deployments.Get(desiredDeployment.Name, metav1.GetOptions{})
+	if err != nil {
+		_, err = deployments.Create(desiredDeployment)
+		if err != nil {
+			return err
 		}
+	} else if !reflect.DeepEqual(existing.Spec.Template, desiredDeployment.Spec.Template) {
+		existing.Spec.Template = desiredDeployment.Spec.Template
+		if _, err := deployments.Update(existing); err != nil {
+			return err
+		}
+	}
 
-		gatewayDeployment := &appv1.Deployment{
-			ObjectMeta: metav1.ObjectMeta{
-				Name:      common.DefaultGatewayDeploymentName(goc.gw.Name),
-				Namespace: goc.gw.Namespace,
-				Labels: map[string]string{
+	if desiredService == nil {
+		return nil
+	}
+	services := goc.controller.kubeClientset.CoreV1().Services(goc.gw.Namespace)
+	existingSvc, err := services.Get(desiredService.Name, metav1.GetOptions{})
+	if err != nil {
+		_, err = services.Create(desiredService)
+		return err
+	}
+	if reflect.DeepEqual(existingSvc.Spec.Ports, desiredService.Spec.Ports) {
+		return nil
+	}
+	existingSvc.Spec.Ports = desiredService.Spec.Ports
+	_, err = services.Update(existingSvc)
+	return err
+}
+
+// defaultDeployment builds the gateway Deployment for gateways that do not reference a
+// GatewayClass, preserving the container layout the controller has always generated.
+func (goc *gwOperationCtx) defaultDeployment() *appv1.Deployment {
+	return &appv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      common.DefaultGatewayDeploymentName(goc.gw.Name),
+			Namespace: goc.gw.Namespace,
+			Labels: map[string]string{
+				common.LabelGatewayName: goc.gw.Name,
+			},
+			OwnerReferences: []metav1.OwnerReference{
+				*metav1.NewControllerRef(goc.gw, v1alpha1.SchemaGroupVersionKind),
+			},
+		},
+		Spec: appv1.DeploymentSpec{
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{
 					common.LabelGatewayName: goc.gw.Name,
 				},
-				OwnerReferences: []metav1.OwnerReference{
-					*metav1.NewControllerRef(goc.gw, v1alpha1.SchemaGroupVersionKind),
-				},
 			},
-			Spec: appv1.DeploymentSpec{
-				Selector: &metav1.LabelSelector{
-					MatchLabels: map[string]string{
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{
 						common.LabelGatewayName: goc.gw.Name,
 					},
 				},
-				Template: corev1.PodTemplateSpec{
-					ObjectMeta: metav1.ObjectMeta{
-						Labels: map[string]string{
-							common.LabelGatewayName: goc.gw.Name,
-						},
-					},
-					Spec: corev1.PodSpec{
-						ServiceAccountName: goc.gw.Spec.ServiceAccountName,
-						Containers: []corev1.Container{
-							{
-								Name:            "gateway-processor",
-								ImagePullPolicy: goc.gw.Spec.ImagePullPolicy,
-								Image:           goc.gw.Spec.Image,
-								Env: []corev1.EnvVar{
-									{
-										Name:  common.GatewayTransformerPortEnvVar,
-										Value: fmt.Sprintf("%d", common.GatewayTransformerPort),
-									},
-									{
-										Name:  common.EnvVarNamespace,
-										Value: goc.gw.Namespace,
-									},
+				Spec: corev1.PodSpec{
+					ServiceAccountName: goc.gw.Spec.ServiceAccountName,
+					Containers: []corev1.Container{
+						{
+							Name:            "gateway-processor",
+							ImagePullPolicy: goc.gw.Spec.ImagePullPolicy,
+							Image:           goc.gw.Spec.Image,
+							Env: []corev1.EnvVar{
+								{
+									Name:  common.GatewayTransformerPortEnvVar,
+									Value: fmt.Sprintf("%d", common.GatewayTransformerPort),
+								},
+								{
+									Name:  common.EnvVarNamespace,
+									Value: goc.gw.Namespace,
 								},
 							},
-							{
-								Name:            "gateway-transformer",
-								ImagePullPolicy: corev1.PullAlways,
-								Image:           common.GatewayEventTransformerImage,
-								Env: []corev1.EnvVar{
-									{
-										Name:  common.GatewayTransformerConfigMapEnvVar,
-										Value: common.DefaultGatewayTransformerConfigMapName(goc.gw.Name),
-									},
-									{
-										Name:  common.EnvVarNamespace,
-										Value: goc.gw.Namespace,
-									},
+						},
+						{
+							Name:            "gateway-transformer",
+							ImagePullPolicy: corev1.PullAlways,
+							Image:           common.GatewayEventTransformerImage,
+							Env: []corev1.EnvVar{
+								{
+									Name:  common.GatewayTransformerConfigMapEnvVar,
+									Value: common.DefaultGatewayTransformerConfigMapName(goc.gw.Name),
+								},
+								{
+									Name:  common.EnvVarNamespace,
+									Value: goc.gw.Namespace,
 								},
 							},
 						},
 					},
 				},
 			},
-		}
-
-		// we can now create the gateway deployment.
-		// depending on user configuration gateway will be exposed outside the cluster or intra-cluster.
-		_, err = goc.controller.kubeClientset.AppsV1().Deployments(goc.gw.Namespace).Create(gatewayDeployment)
-		if err != nil {
-			goc.log.Error().Err(err).Msg("failed gateway deployment")
-			goc.gw.Status = v1alpha1.NodePhaseError
-		} else {
-			goc.gw.Status = v1alpha1.NodePhaseRunning
-			if goc.gw.Spec.Service.Port != 0 {
-				goc.createGatewayService()
-			}
-		}
-
-		// update state of the gateway
-		goc.gw, err = gatewayClient.Update(goc.gw)
-		if err != nil {
-			err = goc.reapplyUpdate(gatewayClient)
-			if err != nil {
-				goc.log.Error().Msg("failed to update gateway")
-				return err
-			}
-		}
-
-		// Gateway is in error
-	case v1alpha1.NodePhaseError:
-		gDeployment, err := goc.controller.kubeClientset.AppsV1().Deployments(goc.gw.Namespace).Get(goc.gw.Name, metav1.GetOptions{})
-		if err != nil {
-			goc.log.Error().Err(err).Msg("error occurred retrieving gateway deployment")
-			return err
-		}
-
-		// If image has been updated
-		gDeployment.Spec.Template.Spec.Containers[0].Image = goc.gw.Spec.Image
-		_, err = goc.controller.kubeClientset.AppsV1().Deployments(goc.gw.Namespace).Update(gDeployment)
-		if err != nil {
-			goc.log.Error().Err(err).Msg("error occurred updating gateway deployment")
-			return err
-		}
-
-		// Update node phase to running
-		goc.gw.Status = v1alpha1.NodePhaseRunning
-		// update state of the gateway
-		goc.gw, err = gatewayClient.Update(goc.gw)
-		if err != nil {
-			err = goc.reapplyUpdate(gatewayClient)
-			if err != nil {
-				goc.log.Error().Err(err).Msg("failed to update gateway")
-				return err
-			}
-		}
-		return nil
-
-		// Gateway is already running, do nothing
-	case v1alpha1.NodePhaseRunning:
-		// Todo: if the sensor to which event should be dispatched changes then update the configmap for gateway pod
-		goc.log.Warn().Msg("gateway is already running")
-	default:
-		goc.log.Panic().Str("phase", string(goc.gw.Status)).Msg("unknown gateway phase.")
+		},
 	}
-	return nil
 }
 
-// Creates a service that exposes gateway outside the cluster
-func (goc *gwOperationCtx) createGatewayService() {
-	gatewayService := &corev1.Service{
+// defaultService builds the gateway Service for gateways that do not reference a GatewayClass.
+func (goc *gwOperationCtx) defaultService() *corev1.Service {
+	return &corev1.Service{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      common.DefaultGatewayServiceName(goc.gw.Name),
 			Namespace: goc.gw.Namespace,
@@ -239,11 +302,53 @@ func (goc *gwOperationCtx) createGatewayService() {
 			Type: corev1.ServiceType(goc.gw.Spec.Service.Type),
 		},
 	}
+}
 
-	_, err := goc.controller.kubeClientset.CoreV1().Services(goc.gw.Namespace).Create(gatewayService)
-	// Fail silently
+// setCondition upserts a status condition by type, stamping lastTransitionTime only when the
+// status actually flips so unrelated reconciliations don't churn it.
+func (goc *gwOperationCtx) setCondition(condType v1alpha1.GatewayConditionType, status corev1.ConditionStatus, reason, message string) {
+	now := metav1.Now()
+	for i, existing := range goc.gw.Status.Conditions {
+		if existing.Type == condType {
+			if existing.Status != status {
+				goc.gw.Status.Conditions[i].LastTransitionTime = now
+			}
+			goc.gw.Status.Conditions[i].Status = status
+			goc.gw.Status.Conditions[i].Reason = reason
+			goc.gw.Status.Conditions[i].Message = message
+			goc.gw.Status.Conditions[i].ObservedGeneration = goc.gw.Generation
+			return
+		}
+	}
+	goc.gw.Status.Conditions = append(goc.gw.Status.Conditions, v1alpha1.GatewayCondition{
+		Type:               condType,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		LastTransitionTime: now,
+		ObservedGeneration: goc.gw.Generation,
+	})
+}
+
+// commitStatus stamps status.observedGeneration and persists the gateway, retrying through
+// reapplyUpdate on conflict so the controller always catches up with the latest spec generation.
+func (goc *gwOperationCtx) commitStatus(gatewayClient client.GatewayInterface) error {
+	goc.gw.Status.ObservedGeneration = goc.gw.Generation
+	var err error
+	goc.gw, err = gatewayClient.Update(goc.gw)
 	if err != nil {
-		goc.log.Error().Err(err).Msg("failed to create service for gateway deployment")
+		return goc.reapplyUpdate(gatewayClient)
+	}
+	return nil
+}
+
+// persistStatus best-effort persists the gateway's status after a failed reconcile step. It
+// only logs a status-update failure rather than returning it, so operate() keeps propagating
+// the original reconcile error to the workqueue instead of masking it with an unrelated status
+// write failure (or, worse, a nil status-write success).
+func (goc *gwOperationCtx) persistStatus(gatewayClient client.GatewayInterface) {
+	if err := goc.commitStatus(gatewayClient); err != nil {
+		goc.log.Error().Err(err).Msg("failed to persist gateway status")
 	}
 }
 
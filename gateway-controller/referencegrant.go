@@ -0,0 +1,76 @@
+package gateway_controller
+
+import (
+	"context"
+
+	"github.com/argoproj/argo-events/pkg/apis/gateway/v1alpha1"
+	client "github.com/argoproj/argo-events/pkg/gateway-client/clientset/versioned/typed/gateway/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// ReferenceGrantLister answers whether a gateway in fromNamespace is permitted to target
+// sensors in grantNamespace, backed by an informer cache of SensorReferenceGrant objects so the
+// check never hits the API server on the request path.
+type ReferenceGrantLister struct {
+	informer cache.SharedIndexInformer
+}
+
+// NewReferenceGrantLister wraps an informer watching SensorReferenceGrant objects across all
+// namespaces.
+func NewReferenceGrantLister(informer cache.SharedIndexInformer) *ReferenceGrantLister {
+	return &ReferenceGrantLister{informer: informer}
+}
+
+// GatewayPermitted reports whether a SensorReferenceGrant in grantNamespace whitelists a
+// gateway named gatewayName in fromNamespace.
+func (rgl *ReferenceGrantLister) GatewayPermitted(grantNamespace, fromNamespace, gatewayName string) (bool, error) {
+	for _, obj := range rgl.informer.GetStore().List() {
+		grant, ok := obj.(*v1alpha1.SensorReferenceGrant)
+		if !ok || grant.Namespace != grantNamespace {
+			continue
+		}
+		for _, from := range grant.Spec.From {
+			if from.Namespace != fromNamespace {
+				continue
+			}
+			if from.Gateway == "" || from.Gateway == gatewayName {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// WatchReferenceGrants re-enqueues every gateway in a grant's "from" list whenever a
+// SensorReferenceGrant is added, updated or deleted, so granting or revoking permission
+// re-triggers reconciliation of the gateways it affects.
+func WatchReferenceGrants(ctx context.Context, gatewayClientset client.ArgoprojV1alpha1Interface, informer cache.SharedIndexInformer, enqueue func(namespace, name string)) {
+	requeueAffected := func(obj interface{}) {
+		grant, ok := obj.(*v1alpha1.SensorReferenceGrant)
+		if !ok {
+			return
+		}
+		for _, from := range grant.Spec.From {
+			if from.Gateway != "" {
+				enqueue(from.Namespace, from.Gateway)
+				continue
+			}
+			gateways, err := gatewayClientset.Gateways(from.Namespace).List(metav1.ListOptions{})
+			if err != nil {
+				continue
+			}
+			for _, gw := range gateways.Items {
+				enqueue(gw.Namespace, gw.Name)
+			}
+		}
+	}
+
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    requeueAffected,
+		UpdateFunc: func(_, newObj interface{}) { requeueAffected(newObj) },
+		DeleteFunc: requeueAffected,
+	})
+
+	go informer.Run(ctx.Done())
+}
@@ -4,12 +4,14 @@ import (
 	"context"
 	"fmt"
 	"github.com/argoproj/argo-events/common"
+	"github.com/argoproj/argo-events/pkg/dispatch"
 	apiv1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/tools/cache"
+	"strconv"
 	"strings"
 )
 
@@ -78,6 +80,10 @@ func (t *tOperationCtx) updateConfig(cm *apiv1.ConfigMap) error {
 	if !ok {
 		return fmt.Errorf("configMap '%s' does not have key '%s'", cm.Name, common.EventTypeVersion)
 	}
+	// sensors is a comma-separated list of targets, same-namespace "name" or cross-namespace
+	// "namespace/name", as accepted by gw.Spec.Sensors and guarded by a SensorReferenceGrant in
+	// the target namespace. The transformer doesn't re-check grants itself; it trusts the
+	// gateway controller to have refused to program any sensor ref it didn't resolve.
 	sensors, ok := cm.Data[common.SensorList]
 	if !ok {
 		return fmt.Errorf("configMap '%s' does not have key '%s'", cm.Name, common.SensorList)
@@ -86,12 +92,138 @@ func (t *tOperationCtx) updateConfig(cm *apiv1.ConfigMap) error {
 	if !ok {
 		return fmt.Errorf("configMap '%s' does not have key '%s'", cm.Name, common.EventSource)
 	}
+	// eventschema is optional and advertises the schema of the data carried by events from
+	// this source as a CloudEvents "eventschema" extension attribute.
+	eventSchema := cm.Data[common.EventSchema]
 
+	// dispatchMechanism defaults to HTTPGateway for ConfigMaps written before this field
+	// existed, preserving the transformer's historical behavior.
+	dispatchMechanism := cm.Data[common.DispatchMechanism]
+	if dispatchMechanism == "" {
+		dispatchMechanism = "HTTPGateway"
+	}
+
+	dispatchConfig := dispatchConfigFromConfigMap(cm.Data)
+	if err := t.resolveDispatchCredentials(dispatchConfig); err != nil {
+		return fmt.Errorf("failed to resolve dispatch credentials for configMap '%s': %w", cm.Name, err)
+	}
+
+	dispatcher, err := dispatch.New(dispatchMechanism, dispatchConfig)
+	if err != nil {
+		return fmt.Errorf("failed to construct dispatcher for configMap '%s': %w", cm.Name, err)
+	}
+
+	// t.Config and t.Dispatcher are only swapped in once the new dispatcher is built
+	// successfully, so a failure above leaves both pointing at the same (old) generation
+	// instead of updating one and not the other.
+	if t.Dispatcher != nil {
+		t.Dispatcher.Close()
+	}
+	t.Dispatcher = dispatcher
 	t.Config = &tConfig{
-		EventType:        eventType,
-		EventTypeVersion: eventTypeVersion,
-		Sensors:          strings.Split(sensors, ","),
-		EventSource:      eventSource,
+		EventType:         eventType,
+		EventTypeVersion:  eventTypeVersion,
+		Sensors:           strings.Split(sensors, ","),
+		EventSource:       eventSource,
+		EventSchema:       eventSchema,
+		DispatchMechanism: dispatchMechanism,
+		Extensions:        extractCloudEventExtensions(cm.Data),
+	}
+	return nil
+}
+
+// dispatchConfigFromConfigMap derives the dispatch.Config a Dispatcher needs to connect to its
+// broker from the transformer ConfigMap: broker addresses, the credentials secret reference and
+// the per-sensor routing keys (e.g. the NATS subject or Kafka topic to publish to for a sensor).
+func dispatchConfigFromConfigMap(data map[string]string) *dispatch.Config {
+	config := &dispatch.Config{
+		CredentialsSecretName: data[common.EventBusCredentialsSecretName],
+		CredentialsSecretKey:  data[common.EventBusCredentialsSecretKey],
+		PartitionKey:          data[common.EventBusPartitionKey],
+		SensorRoutingKeys:     make(map[string]string),
+	}
+	// without an explicit partition key, events are keyed by their EventSource so all events
+	// from the same source land on the same Kafka partition.
+	if config.PartitionKey == "" {
+		config.PartitionKey = data[common.EventSource]
+	}
+	if addresses, ok := data[common.EventBusAddresses]; ok && addresses != "" {
+		config.Addresses = strings.Split(addresses, ",")
+	}
+	if qos, err := strconv.Atoi(data[common.EventBusQoS]); err == nil {
+		config.QoS = byte(qos)
+	}
+	for key, value := range data {
+		if strings.HasPrefix(key, common.SensorRoutingKeyPrefix) {
+			sensor := strings.TrimPrefix(key, common.SensorRoutingKeyPrefix)
+			config.SensorRoutingKeys[sensor] = value
+		}
+	}
+	return config
+}
+
+// resolveDispatchCredentials fetches the Kubernetes secret named by config.CredentialsSecretName
+// and populates config.Username/Password from it, so pkg/dispatch (which has no Kubernetes
+// client) never has to talk to the API server itself. It is a no-op for dispatch mechanisms with
+// no credentials secret configured. The secret value at CredentialsSecretKey is expected to be
+// either a bare token/password, or "username:password" for brokers that authenticate with both.
+func (t *tOperationCtx) resolveDispatchCredentials(config *dispatch.Config) error {
+	if config.CredentialsSecretName == "" {
+		return nil
+	}
+	secret, err := t.kubeClientset.CoreV1().Secrets(t.Namespace).Get(config.CredentialsSecretName, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to get secret '%s': %w", config.CredentialsSecretName, err)
+	}
+	value, ok := secret.Data[config.CredentialsSecretKey]
+	if !ok {
+		return fmt.Errorf("secret '%s' has no key '%s'", config.CredentialsSecretName, config.CredentialsSecretKey)
+	}
+	if username, password, found := strings.Cut(string(value), ":"); found {
+		config.Username = username
+		config.Password = password
+	} else {
+		config.Password = string(value)
+	}
+	return nil
+}
+
+// DispatchEvent routes payload to every sensor this gateway is configured to reach, using
+// whatever Dispatcher updateConfig last constructed from the transformer ConfigMap. This is the
+// call site that ties the per-event path to the dispatch.Dispatcher abstraction: the
+// transformer's event-ingress handler (the code that actually terminates an incoming event and
+// decides it's time to fan it out) is not part of this checkout, so it cannot be wired to call
+// DispatchEvent directly here. Once that handler exists, it calls DispatchEvent with the event
+// payload it received instead of touching t.Dispatcher itself.
+func (t *tOperationCtx) DispatchEvent(payload []byte) error {
+	if t.Dispatcher == nil {
+		return fmt.Errorf("no dispatcher configured, dropping event")
+	}
+	if t.Config == nil {
+		return fmt.Errorf("no config available to resolve sensor targets")
+	}
+	var errs []string
+	for _, sensor := range t.Config.Sensors {
+		if err := t.Dispatcher.Dispatch(sensor, payload); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", sensor, err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to dispatch event to sensor(s): %s", strings.Join(errs, "; "))
 	}
 	return nil
 }
+
+// extractCloudEventExtensions pulls out any "ce-extension-*" keys from the transformer
+// ConfigMap and turns them into CloudEvents extension attributes, keyed by the part of the
+// ConfigMap key following the prefix.
+func extractCloudEventExtensions(data map[string]string) map[string]string {
+	extensions := make(map[string]string)
+	for key, value := range data {
+		if strings.HasPrefix(key, common.CloudEventExtensionPrefix) {
+			name := strings.TrimPrefix(key, common.CloudEventExtensionPrefix)
+			extensions[name] = value
+		}
+	}
+	return extensions
+}
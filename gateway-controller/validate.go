@@ -0,0 +1,146 @@
+package gateway_controller
+
+import (
+	"fmt"
+	"github.com/argoproj/argo-events/pkg/apis/gateway/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"strings"
+)
+
+// Validates the gateway resource
+func (goc *gwOperationCtx) validate() error {
+	// A gateway that references a GatewayClass gets its pod/service spec from the class
+	// template (see renderFromClass); DeploySpec there is an optional overlay, not the only
+	// source of a deploy spec, so it's not required up front.
+	if goc.gw.Spec.DeploySpec == nil && goc.gw.Spec.GatewayClassName == "" {
+		return fmt.Errorf("gateway deploy specification is not specified")
+	}
+	if goc.gw.Spec.Type == "" {
+		return fmt.Errorf("gateway type is not specified")
+	}
+	if goc.gw.Spec.Version == "" {
+		return fmt.Errorf("gateway version is not specified")
+	}
+	if err := goc.validateDispatchMechanism(); err != nil {
+		return err
+	}
+	return goc.resolveSensorRefs()
+}
+
+// sensorRef is a sensor target parsed from gw.Spec.Sensors, which now accepts plain "name"
+// (same-namespace, the historical behavior) as well as "namespace/name" for cross-namespace
+// targets.
+type sensorRef struct {
+	Namespace string
+	Name      string
+}
+
+// parseSensorRef splits a gw.Spec.Sensors entry into its namespace and name, defaulting the
+// namespace to the gateway's own namespace when the entry carries no "namespace/" prefix.
+func parseSensorRef(raw string, defaultNamespace string) sensorRef {
+	if ns, name, found := strings.Cut(raw, "/"); found {
+		return sensorRef{Namespace: ns, Name: name}
+	}
+	return sensorRef{Namespace: defaultNamespace, Name: raw}
+}
+
+// resolveSensorRefs checks every sensor this gateway targets. Same-namespace sensors need no
+// further permission. Cross-namespace sensors must be whitelisted by a SensorReferenceGrant
+// living in the sensor's own namespace - the gateway cannot unilaterally grant itself access to
+// another namespace's sensors.
+//
+// A sensor ref that isn't permitted does not fail validate(): it is left out of
+// goc.resolvedSensors, so reconcileConfigMap only programs routes for the sensors this gateway
+// is actually allowed to reach, and every other same-namespace or granted sensor keeps working.
+// Only a failure to even evaluate a SensorReferenceGrant (an API error, not a policy decision)
+// fails validation, since at that point the gateway can't tell whether the ref should be
+// permitted or not.
+func (goc *gwOperationCtx) resolveSensorRefs() error {
+	var permitted, denied []string
+	for _, raw := range goc.gw.Spec.Sensors {
+		ref := parseSensorRef(raw, goc.gw.Namespace)
+		if ref.Namespace == goc.gw.Namespace {
+			permitted = append(permitted, raw)
+			continue
+		}
+		granted, err := goc.controller.referenceGrantLister.GatewayPermitted(ref.Namespace, goc.gw.Namespace, goc.gw.Name)
+		if err != nil {
+			goc.setResolvedRefsCondition(false, "GrantLookupFailed", err.Error())
+			return fmt.Errorf("failed to check SensorReferenceGrant for sensor '%s': %w", raw, err)
+		}
+		if !granted {
+			denied = append(denied, raw)
+			continue
+		}
+		permitted = append(permitted, raw)
+	}
+	goc.resolvedSensors = permitted
+
+	if len(denied) > 0 {
+		message := fmt.Sprintf("gateway '%s/%s' is not permitted to target sensor(s) %s: no matching SensorReferenceGrant; routes for these sensors were not programmed", goc.gw.Namespace, goc.gw.Name, strings.Join(denied, ", "))
+		goc.setResolvedRefsCondition(false, "RefNotPermitted", message)
+		return nil
+	}
+	goc.setResolvedRefsCondition(true, "Resolved", "all sensor references are same-namespace or covered by a SensorReferenceGrant")
+	return nil
+}
+
+// setResolvedRefsCondition upserts the Gateway's ResolvedRefs condition, mirroring the
+// type/status/reason/message/observedGeneration shape used for the controller's other
+// conditions.
+func (goc *gwOperationCtx) setResolvedRefsCondition(ok bool, reason, message string) {
+	status := corev1.ConditionFalse
+	if ok {
+		status = corev1.ConditionTrue
+	}
+	now := metav1.Now()
+	for i, existing := range goc.gw.Status.Conditions {
+		if existing.Type == v1alpha1.GatewayConditionResolvedRefs {
+			if existing.Status != status {
+				goc.gw.Status.Conditions[i].LastTransitionTime = now
+			}
+			goc.gw.Status.Conditions[i].Status = status
+			goc.gw.Status.Conditions[i].Reason = reason
+			goc.gw.Status.Conditions[i].Message = message
+			goc.gw.Status.Conditions[i].ObservedGeneration = goc.gw.Generation
+			return
+		}
+	}
+	goc.gw.Status.Conditions = append(goc.gw.Status.Conditions, v1alpha1.GatewayCondition{
+		Type:               v1alpha1.GatewayConditionResolvedRefs,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		LastTransitionTime: now,
+		ObservedGeneration: goc.gw.Generation,
+	})
+}
+
+// validateDispatchMechanism checks the settings required by the gateway's DispatchMechanism.
+// Only HTTPGateway dispatches by directly invoking sensor watchers, so the watchers requirement
+// no longer applies across the board: event-bus mechanisms instead validate that they carry
+// enough connection information for the transformer sidecar to construct a dispatch.Dispatcher.
+func (goc *gwOperationCtx) validateDispatchMechanism() error {
+	switch goc.gw.Spec.DispatchMechanism {
+	case v1alpha1.HTTPGateway:
+		if goc.gw.Spec.Watchers == nil || (goc.gw.Spec.Watchers.Gateways == nil && goc.gw.Spec.Watchers.Sensors == nil) {
+			return fmt.Errorf("no associated watchers with gateway")
+		}
+	case v1alpha1.NATSGateway:
+		if goc.gw.Spec.EventBus == nil || len(goc.gw.Spec.EventBus.Addresses) == 0 {
+			return fmt.Errorf("no NATS server addresses configured for gateway")
+		}
+	case v1alpha1.KafkaGateway:
+		if goc.gw.Spec.EventBus == nil || len(goc.gw.Spec.EventBus.Addresses) == 0 {
+			return fmt.Errorf("no Kafka broker addresses configured for gateway")
+		}
+	case v1alpha1.MQTTGateway:
+		if goc.gw.Spec.EventBus == nil || len(goc.gw.Spec.EventBus.Addresses) == 0 {
+			return fmt.Errorf("no MQTT broker address configured for gateway")
+		}
+	default:
+		return fmt.Errorf("unknown gateway type")
+	}
+	return nil
+}
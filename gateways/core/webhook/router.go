@@ -0,0 +1,255 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/argoproj/argo-events/common"
+	"github.com/fsnotify/fsnotify"
+	zlog "github.com/rs/zerolog"
+)
+
+// routeKey identifies a single (endpoint, method) pair within a listener.
+type routeKey struct {
+	endpoint string
+	method   string
+}
+
+// route holds the handler registered for a routeKey, plus how many hook configs currently
+// reference it so the last one to stop can remove it.
+type route struct {
+	handler  http.HandlerFunc
+	refCount int
+}
+
+// router is a dynamic, per-listener routing table. Unlike http.DefaultServeMux it supports
+// true removal of routes at runtime: once the last config referencing a route stops, the route
+// is deleted and subsequent requests to it get a 404/405 instead of a handler that was only
+// ever gated by the now-stale activeRoutes map.
+type router struct {
+	mu     sync.RWMutex
+	routes map[routeKey]*route
+}
+
+func newRouter() *router {
+	return &router{routes: make(map[routeKey]*route)}
+}
+
+// ServeHTTP looks up the exact (path, method) pair. An unknown path is a 404; a known path with
+// no handler for the request's method is a 405, mirroring standard REST semantics.
+func (r *router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var methodKnown bool
+	for key, rt := range r.routes {
+		if key.endpoint != req.URL.Path {
+			continue
+		}
+		methodKnown = true
+		if key.method == req.Method {
+			rt.handler(w, req)
+			return
+		}
+	}
+	if methodKnown {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	http.Error(w, "not found", http.StatusNotFound)
+}
+
+// addRoute registers a handler for (endpoint, method), bumping the reference count if a hook
+// config already registered the same pair (e.g. a second config sharing the listener).
+func (r *router) addRoute(endpoint, method string, handler http.HandlerFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := routeKey{endpoint: endpoint, method: method}
+	if rt, ok := r.routes[key]; ok {
+		rt.refCount++
+		return
+	}
+	r.routes[key] = &route{handler: handler, refCount: 1}
+}
+
+// removeRoute drops the reference held by a stopped hook config, deleting the route entirely
+// once nothing references it any longer so traffic genuinely stops being served.
+func (r *router) removeRoute(endpoint, method string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := routeKey{endpoint: endpoint, method: method}
+	rt, ok := r.routes[key]
+	if !ok {
+		return
+	}
+	rt.refCount--
+	if rt.refCount <= 0 {
+		delete(r.routes, key)
+	}
+}
+
+// empty reports whether the router has no routes left, used to decide whether the listener
+// backing it should be shut down.
+func (r *router) empty() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.routes) == 0
+}
+
+// listener bundles an http.Server with the router of routes it serves and, for TLS listeners,
+// the file watcher that reloads the certificate when it changes on disk.
+type listener struct {
+	server   *http.Server
+	router   *router
+	certWatcher *fsnotify.Watcher
+}
+
+// listenerRegistry tracks one listener per port so that multiple hook configs sharing a port
+// register routes on the same *http.Server instead of each trying to bind it.
+type listenerRegistry struct {
+	mu        sync.Mutex
+	listeners map[string]*listener
+	log       zlog.Logger
+}
+
+func newListenerRegistry(log zlog.Logger) *listenerRegistry {
+	return &listenerRegistry{
+		listeners: make(map[string]*listener),
+		log:       log,
+	}
+}
+
+// registerRoute adds a route for (endpoint, method) on port, starting the listener's http.Server
+// the first time a route is registered against that port. certPath/keyPath being non-empty
+// enables TLS with reload-on-change; both empty means plain HTTP. Ensuring the listener and
+// adding the route happen under the same lr.mu critical section as unregisterRoute's
+// empty-check-and-teardown, so a route can never be added to a listener that is concurrently
+// being shut down because it looked empty a moment before.
+func (lr *listenerRegistry) registerRoute(port, certPath, keyPath, endpoint, method string, handler http.HandlerFunc) error {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+
+	l, err := lr.ensureListenerLocked(port, certPath, keyPath)
+	if err != nil {
+		return err
+	}
+	l.router.addRoute(endpoint, method, handler)
+	return nil
+}
+
+// unregisterRoute removes the route for (endpoint, method) on port and, if that was the last
+// route left on the listener, shuts the listener down. The removal and the empty-check-and-delete
+// happen under the same lr.mu critical section registerRoute uses, closing the race where a
+// concurrent registerRoute could add a new route to a listener this call had already decided to
+// tear down.
+func (lr *listenerRegistry) unregisterRoute(port, endpoint, method string) {
+	lr.mu.Lock()
+	l, ok := lr.listeners[port]
+	if !ok {
+		lr.mu.Unlock()
+		return
+	}
+	l.router.removeRoute(endpoint, method)
+	if !l.router.empty() {
+		lr.mu.Unlock()
+		return
+	}
+	delete(lr.listeners, port)
+	lr.mu.Unlock()
+
+	if l.certWatcher != nil {
+		l.certWatcher.Close()
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), common.DefaultShutdownTimeout)
+	defer cancel()
+	if err := l.server.Shutdown(ctx); err != nil {
+		lr.log.Error().Err(err).Str("port", port).Msg("error shutting down http server")
+	}
+}
+
+// ensureListenerLocked returns the listener for a port, starting its http.Server the first time a
+// route is registered against that port. Callers must hold lr.mu.
+func (lr *listenerRegistry) ensureListenerLocked(port, certPath, keyPath string) (*listener, error) {
+	if l, ok := lr.listeners[port]; ok {
+		return l, nil
+	}
+
+	rtr := newRouter()
+	srv := &http.Server{
+		Addr:    ":" + port,
+		Handler: rtr,
+	}
+	l := &listener{server: srv, router: rtr}
+
+	if certPath != "" && keyPath != "" {
+		cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+		}
+		certStore := &reloadableCert{cert: &cert}
+		srv.TLSConfig = &tls.Config{GetCertificate: certStore.get}
+
+		watcher, err := fsnotify.NewWatcher()
+		if err != nil {
+			return nil, fmt.Errorf("failed to start certificate watcher: %w", err)
+		}
+		if err := watcher.Add(certPath); err != nil {
+			return nil, err
+		}
+		if err := watcher.Add(keyPath); err != nil {
+			return nil, err
+		}
+		l.certWatcher = watcher
+		go certStore.watch(watcher, certPath, keyPath, lr.log)
+
+		go func() {
+			lr.log.Info().Str("port", port).Msg("https server started listening...")
+			if err := srv.ListenAndServeTLS("", ""); err != nil && err != http.ErrServerClosed {
+				lr.log.Error().Err(err).Str("port", port).Msg("https server stopped")
+			}
+		}()
+	} else {
+		go func() {
+			lr.log.Info().Str("port", port).Msg("http server started listening...")
+			if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				lr.log.Error().Err(err).Str("port", port).Msg("http server stopped")
+			}
+		}()
+	}
+
+	lr.listeners[port] = l
+	return l, nil
+}
+
+// reloadableCert serves the current certificate to TLS handshakes and swaps it out in place
+// whenever the on-disk cert/key pair changes, so rotations don't require a process restart.
+type reloadableCert struct {
+	mu   sync.RWMutex
+	cert *tls.Certificate
+}
+
+func (rc *reloadableCert) get(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	rc.mu.RLock()
+	defer rc.mu.RUnlock()
+	return rc.cert, nil
+}
+
+func (rc *reloadableCert) watch(watcher *fsnotify.Watcher, certPath, keyPath string, log zlog.Logger) {
+	for event := range watcher.Events {
+		if event.Op&(fsnotify.Write|fsnotify.Create) == 0 {
+			continue
+		}
+		cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+		if err != nil {
+			log.Error().Err(err).Msg("failed to reload TLS certificate")
+			continue
+		}
+		rc.mu.Lock()
+		rc.cert = &cert
+		rc.mu.Unlock()
+		log.Info().Msg("reloaded TLS certificate")
+	}
+}
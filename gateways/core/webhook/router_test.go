@@ -0,0 +1,131 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	zlog "github.com/rs/zerolog"
+)
+
+func TestRouterConcurrentAddRemove(t *testing.T) {
+	r := newRouter()
+	const endpoint = "/events"
+	const method = http.MethodPost
+	const routines = 20
+
+	var wg sync.WaitGroup
+	wg.Add(routines)
+	for i := 0; i < routines; i++ {
+		go func() {
+			defer wg.Done()
+			r.addRoute(endpoint, method, func(w http.ResponseWriter, req *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})
+		}()
+	}
+	wg.Wait()
+
+	if r.empty() {
+		t.Fatalf("expected route to still be registered after %d concurrent adds", routines)
+	}
+
+	req := httptest.NewRequest(method, endpoint, nil)
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d while route is registered, got %d", http.StatusOK, rec.Code)
+	}
+
+	wg.Add(routines)
+	for i := 0; i < routines; i++ {
+		go func() {
+			defer wg.Done()
+			r.removeRoute(endpoint, method)
+		}()
+	}
+	wg.Wait()
+
+	if !r.empty() {
+		t.Fatalf("expected router to be empty after removing every reference")
+	}
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected status %d once every handler has been removed, got %d", http.StatusNotFound, rec.Code)
+	}
+}
+
+func TestRouterMethodNotAllowed(t *testing.T) {
+	r := newRouter()
+	r.addRoute("/events", http.MethodPost, func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/events", nil)
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected status %d for a known path with no handler for the method, got %d", http.StatusMethodNotAllowed, rec.Code)
+	}
+}
+
+func TestListenerRegistryUnregisterStopsServingTraffic(t *testing.T) {
+	lr := newListenerRegistry(zlog.New(ioutil.Discard))
+	const endpoint = "/events"
+	const method = http.MethodPost
+
+	var wg sync.WaitGroup
+	const configs = 5
+	errs := make([]error, configs)
+	wg.Add(configs)
+	for i := 0; i < configs; i++ {
+		i := i
+		go func() {
+			defer wg.Done()
+			errs[i] = lr.registerRoute("0", "", "", endpoint, method, func(w http.ResponseWriter, req *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})
+		}()
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			t.Fatalf("unexpected error registering route: %v", err)
+		}
+	}
+
+	lr.mu.Lock()
+	l, ok := lr.listeners["0"]
+	lr.mu.Unlock()
+	if !ok {
+		t.Fatalf("expected a listener to be registered for port 0")
+	}
+
+	wg.Add(configs)
+	for i := 0; i < configs; i++ {
+		go func() {
+			defer wg.Done()
+			lr.unregisterRoute("0", endpoint, method)
+		}()
+	}
+	wg.Wait()
+
+	if !l.router.empty() {
+		t.Fatalf("expected every route reference to be released")
+	}
+	rec := httptest.NewRecorder()
+	l.router.ServeHTTP(rec, httptest.NewRequest(method, endpoint, nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected status %d once every config has unregistered, got %d", http.StatusNotFound, rec.Code)
+	}
+	lr.mu.Lock()
+	_, stillRegistered := lr.listeners["0"]
+	lr.mu.Unlock()
+	if stillRegistered {
+		t.Fatalf("expected the listener to be removed from the registry once its router emptied")
+	}
+}
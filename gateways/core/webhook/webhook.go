@@ -18,30 +18,234 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"github.com/argoproj/argo-events/common"
 	gateways "github.com/argoproj/argo-events/gateways/core"
 	"github.com/ghodss/yaml"
+	"github.com/google/uuid"
 	zlog "github.com/rs/zerolog"
-	"go.uber.org/atomic"
 	"io/ioutil"
 	"net/http"
 	"os"
-	"sync"
+	"strings"
+	"time"
 )
 
-var (
-	// whether http server has started or not
-	hasServerStarted atomic.Bool
+const (
+	// cloudEventsSpecVersion is the CloudEvents spec version this gateway emits and understands.
+	cloudEventsSpecVersion = "1.0"
 
-	// as http package does not provide method for unregistering routes,
-	// this keeps track of configured http routes and their methods.
-	// keeps endpoints as keys and corresponding http methods as a map
-	activeRoutes = make(map[string]map[string]struct{})
+	// cloudEventsContentType is the content type used for the structured JSON mode of CloudEvents.
+	cloudEventsContentType = "application/cloudevents+json"
 
-	mut sync.Mutex
+	// ceHeaderPrefix is the HTTP header prefix used by the CloudEvents binary content mode.
+	ceHeaderPrefix = "Ce-"
+
+	// eventFormatBinary selects hook.EventFormat's binary-mode CloudEvents encoding.
+	eventFormatBinary = "binary"
 )
 
+// cloudEvent is a minimal CloudEvents v1.0 envelope. It is populated for every event the
+// webhook gateway dispatches, and is also used to decode CloudEvents arriving in structured mode.
+type cloudEvent struct {
+	SpecVersion     string            `json:"specversion"`
+	ID              string            `json:"id"`
+	Source          string            `json:"source"`
+	Type            string            `json:"type"`
+	Time            time.Time         `json:"time"`
+	DataContentType string            `json:"datacontenttype,omitempty"`
+	Subject         string            `json:"subject,omitempty"`
+	Data            json.RawMessage   `json:"data,omitempty"`
+	Extensions      map[string]string `json:"-"`
+}
+
+// registry holds every listener the webhook gateway has started, keyed by port, and the routes
+// registered against each of them. Routes are added/removed as hook configs start/stop, and a
+// listener is torn down once its last route is removed.
+var registry = newListenerRegistry(zlog.New(os.Stdout).With().Str("component", "webhook-router").Logger())
+
+// MarshalJSON renders the envelope in CloudEvents structured JSON format, flattening
+// extension attributes to the top level as required by the spec.
+func (ce *cloudEvent) MarshalJSON() ([]byte, error) {
+	attrs := map[string]interface{}{
+		"specversion": ce.SpecVersion,
+		"id":          ce.ID,
+		"source":      ce.Source,
+		"type":        ce.Type,
+		"time":        ce.Time,
+	}
+	if ce.DataContentType != "" {
+		attrs["datacontenttype"] = ce.DataContentType
+	}
+	if ce.Subject != "" {
+		attrs["subject"] = ce.Subject
+	}
+	if len(ce.Data) > 0 {
+		attrs["data"] = ce.Data
+	}
+	for k, v := range ce.Extensions {
+		attrs[k] = v
+	}
+	return json.Marshal(attrs)
+}
+
+// UnmarshalJSON decodes a CloudEvents structured-mode JSON body. Any attribute that isn't one of
+// the named fields is captured into Extensions instead of being silently dropped, so it round-trips
+// back out through MarshalJSON.
+func (ce *cloudEvent) UnmarshalJSON(data []byte) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	fields := map[string]interface{}{
+		"specversion":     &ce.SpecVersion,
+		"id":              &ce.ID,
+		"source":          &ce.Source,
+		"type":            &ce.Type,
+		"time":            &ce.Time,
+		"datacontenttype": &ce.DataContentType,
+		"subject":         &ce.Subject,
+		"data":            &ce.Data,
+	}
+	for name, target := range fields {
+		if value, ok := raw[name]; ok {
+			if err := json.Unmarshal(value, target); err != nil {
+				return fmt.Errorf("failed to decode CloudEvents attribute '%s': %w", name, err)
+			}
+			delete(raw, name)
+		}
+	}
+	if len(raw) == 0 {
+		return nil
+	}
+	ce.Extensions = make(map[string]string, len(raw))
+	for name, value := range raw {
+		var s string
+		if err := json.Unmarshal(value, &s); err == nil {
+			ce.Extensions[name] = s
+			continue
+		}
+		ce.Extensions[name] = string(value)
+	}
+	return nil
+}
+
+// newCloudEvent builds a CloudEvents v1.0 envelope around a raw event payload dispatched
+// through a webhook configuration.
+func newCloudEvent(h *hook, configSrc string, body []byte) *cloudEvent {
+	return &cloudEvent{
+		SpecVersion:     cloudEventsSpecVersion,
+		ID:              uuid.New().String(),
+		Source:          fmt.Sprintf("/gateways/%s/%s", os.Getenv(common.EnvVarNamespace), h.Endpoint),
+		Type:            fmt.Sprintf("%s.%s", h.Endpoint, h.Method),
+		Time:            time.Now().UTC(),
+		DataContentType: "application/json",
+		Subject:         configSrc,
+		Data:            json.RawMessage(body),
+	}
+}
+
+// MarshalBinary renders the envelope the way CloudEvents binary HTTP mode would: one key per
+// attribute, "Ce-"-prefixed, alongside the raw event data. DispatchEvent only carries a single
+// byte-slice payload rather than a separate header channel, so this is how the webhook gateway
+// represents binary mode over that channel instead of setting actual "Ce-*" HTTP headers.
+func (ce *cloudEvent) MarshalBinary() ([]byte, error) {
+	attrs := map[string]interface{}{
+		"ce-specversion": ce.SpecVersion,
+		"ce-id":          ce.ID,
+		"ce-source":      ce.Source,
+		"ce-type":        ce.Type,
+		"ce-time":        ce.Time,
+	}
+	if ce.DataContentType != "" {
+		attrs["content-type"] = ce.DataContentType
+	}
+	if ce.Subject != "" {
+		attrs["ce-subject"] = ce.Subject
+	}
+	for k, v := range ce.Extensions {
+		attrs["ce-"+k] = v
+	}
+	if len(ce.Data) > 0 {
+		attrs["data"] = ce.Data
+	}
+	return json.Marshal(attrs)
+}
+
+// encode renders ce in the wire format requested by h.EventFormat ("binary", or "structured" -
+// the default, preserving the gateway's historical behavior).
+func encode(h *hook, ce *cloudEvent) ([]byte, error) {
+	if h.EventFormat == eventFormatBinary {
+		return ce.MarshalBinary()
+	}
+	return json.Marshal(ce)
+}
+
+// isStructuredCloudEvent reports whether the incoming request carries a CloudEvents
+// structured-mode JSON body.
+func isStructuredCloudEvent(request *http.Request) bool {
+	return strings.HasPrefix(request.Header.Get("Content-Type"), cloudEventsContentType)
+}
+
+// isBinaryCloudEvent reports whether the incoming request carries a CloudEvents binary-mode
+// event, i.e. its attributes are set via "Ce-*" headers.
+func isBinaryCloudEvent(request *http.Request) bool {
+	return request.Header.Get(ceHeaderPrefix+"Specversion") != ""
+}
+
+// binaryCloudEventAttrs are the "Ce-*" headers decodeIncomingCloudEvent maps onto named
+// cloudEvent fields rather than treating as an extension attribute.
+var binaryCloudEventAttrs = map[string]bool{
+	"specversion": true,
+	"id":          true,
+	"source":      true,
+	"type":        true,
+	"subject":     true,
+	"time":        true,
+}
+
+// decodeIncomingCloudEvent reconstructs the cloudEvent envelope from an incoming request that
+// is already a CloudEvent, so the gateway can pass its attributes through instead of re-wrapping.
+func decodeIncomingCloudEvent(request *http.Request, body []byte) (*cloudEvent, error) {
+	if isStructuredCloudEvent(request) {
+		ce := &cloudEvent{}
+		if err := json.Unmarshal(body, ce); err != nil {
+			return nil, err
+		}
+		return ce, nil
+	}
+	ce := &cloudEvent{
+		SpecVersion:     request.Header.Get(ceHeaderPrefix + "Specversion"),
+		ID:              request.Header.Get(ceHeaderPrefix + "Id"),
+		Source:          request.Header.Get(ceHeaderPrefix + "Source"),
+		Type:            request.Header.Get(ceHeaderPrefix + "Type"),
+		DataContentType: request.Header.Get("Content-Type"),
+		Subject:         request.Header.Get(ceHeaderPrefix + "Subject"),
+		Data:            json.RawMessage(body),
+	}
+	if t := request.Header.Get(ceHeaderPrefix + "Time"); t != "" {
+		if parsed, err := time.Parse(time.RFC3339, t); err == nil {
+			ce.Time = parsed
+		}
+	}
+	extensions := make(map[string]string)
+	for name := range request.Header {
+		if !strings.HasPrefix(name, ceHeaderPrefix) {
+			continue
+		}
+		attr := strings.ToLower(strings.TrimPrefix(name, ceHeaderPrefix))
+		if binaryCloudEventAttrs[attr] {
+			continue
+		}
+		extensions[attr] = request.Header.Get(name)
+	}
+	if len(extensions) > 0 {
+		ce.Extensions = extensions
+	}
+	return ce, nil
+}
+
 // hook is a general purpose REST API
 type hook struct {
 	// REST API endpoint
@@ -53,6 +257,17 @@ type hook struct {
 
 	// Port on which HTTP server is listening for incoming events.
 	Port string `json:"port,omitempty" protobuf:"bytes,3,opt,name=port"`
+
+	// CertPath is the path to a TLS certificate. If set along with KeyPath, the listener for
+	// Port serves HTTPS and reloads the certificate whenever it changes on disk.
+	CertPath string `json:"certPath,omitempty" protobuf:"bytes,4,opt,name=certPath"`
+
+	// KeyPath is the path to the TLS private key corresponding to CertPath.
+	KeyPath string `json:"keyPath,omitempty" protobuf:"bytes,5,opt,name=keyPath"`
+
+	// EventFormat selects the CloudEvents wire format dispatched to the gateway-processor:
+	// "structured" (the default) or "binary". See cloudEvent.MarshalJSON/MarshalBinary.
+	EventFormat string `json:"eventFormat,omitempty" protobuf:"bytes,6,opt,name=eventFormat"`
 }
 
 type webhook struct {
@@ -78,78 +293,57 @@ func (w *webhook) RunConfiguration(config *gateways.ConfigData) error {
 	}
 	w.log.Info().Interface("config", config.Config).Interface("hook", h).Msg("configuring...")
 
-	// start a http server only if given configuration contains port information and no other
-	// configuration previously started the server
-	if h.Port != "" && !hasServerStarted.Load() {
-		// mark http server as started
-		hasServerStarted.Store(true)
-		go func() {
-			w.log.Info().Str("http-port", h.Port).Msg("http server started listening...")
-			w.log.Fatal().Err(http.ListenAndServe(":"+fmt.Sprintf("%s", h.Port), nil)).Msg("failed to start http server")
-		}()
+	if h.Endpoint == "" || h.Method == "" || h.Port == "" {
+		return fmt.Errorf("endpoint, method and port must all be specified")
 	}
 
-	var wg sync.WaitGroup
-	wg.Add(1)
-
-	// waits till disconnection from client. perform cleanup.
-	go func() {
-		<-config.StopCh
-		w.log.Info().Str("config-key", config.Src).Msg("stopping the configuration...")
+	err = registry.registerRoute(h.Port, h.CertPath, h.KeyPath, h.Endpoint, h.Method, func(writer http.ResponseWriter, request *http.Request) {
+		w.log.Info().Str("endpoint", h.Endpoint).Str("http-method", h.Method).Msg("received a request")
+		body, err := ioutil.ReadAll(request.Body)
+		if err != nil {
+			w.log.Error().Err(err).Msg("failed to parse request body")
+			common.SendErrorResponse(writer)
+			return
+		}
+		w.log.Info().Str("endpoint", h.Endpoint).Str("http-method", h.Method).Msg("dispatching event to gateway-processor")
+		common.SendSuccessResponse(writer)
 
-		// remove the endpoint and http method configuration.
-		mut.Lock()
-		activeHTTPMethods := activeRoutes[h.Endpoint]
-		delete(activeHTTPMethods, h.Method)
-		mut.Unlock()
+		// if the request already carries a CloudEvent, pass its attributes through
+		// rather than re-wrapping it in a new envelope.
+		var ce *cloudEvent
+		if isStructuredCloudEvent(request) || isBinaryCloudEvent(request) {
+			ce, err = decodeIncomingCloudEvent(request, body)
+			if err != nil {
+				w.log.Warn().Err(err).Str("endpoint", h.Endpoint).Msg("failed to decode incoming CloudEvent, wrapping as a new event")
+				ce = nil
+			}
+		}
+		if ce == nil {
+			ce = newCloudEvent(h, config.Src, body)
+		}
 
-		wg.Done()
-	}()
+		payload, err := encode(h, ce)
+		if err != nil {
+			w.log.Error().Err(err).Msg("failed to marshal CloudEvent payload")
+			return
+		}
+		// dispatch event to gateway transformer
+		w.gatewayConfig.DispatchEvent(payload, config.Src)
+	})
+	if err != nil {
+		w.log.Error().Err(err).Str("port", h.Port).Msg("failed to start listener")
+		return err
+	}
 
 	config.Active = true
-	// configure endpoint and http method
-	if h.Endpoint != "" && h.Method != "" {
-		if _, ok := activeRoutes[h.Endpoint]; !ok {
-			mut.Lock()
-			activeRoutes[h.Endpoint] = make(map[string]struct{})
-			// save event channel for this connection/configuration
-			activeRoutes[h.Endpoint][h.Method] = struct{}{}
-			mut.Unlock()
-
-			// add a handler for endpoint if not already added.
-			http.HandleFunc(h.Endpoint, func(writer http.ResponseWriter, request *http.Request) {
-				// check if http methods match and route and http method is registered.
-				if _, ok := activeRoutes[h.Endpoint]; ok {
-					if _, isActive := activeRoutes[h.Endpoint][request.Method]; isActive {
-						w.log.Info().Str("endpoint", h.Endpoint).Str("http-method", h.Method).Msg("received a request")
-						body, err := ioutil.ReadAll(request.Body)
-						if err != nil {
-							w.log.Error().Err(err).Msg("failed to parse request body")
-							common.SendErrorResponse(writer)
-						} else {
-							w.log.Info().Str("endpoint", h.Endpoint).Str("http-method", h.Method).Msg("dispatching event to gateway-processor")
-							common.SendSuccessResponse(writer)
-							// dispatch event to gateway transformer
-							w.gatewayConfig.DispatchEvent(body, config.Src)
-						}
-					} else {
-						w.log.Warn().Str("endpoint", h.Endpoint).Str("http-method", request.Method).Msg("endpoint and http method is not an active route")
-						common.SendErrorResponse(writer)
-					}
-				} else {
-					w.log.Warn().Str("endpoint", h.Endpoint).Msg("endpoint is not active")
-					common.SendErrorResponse(writer)
-				}
-			})
-		} else {
-			mut.Lock()
-			activeRoutes[h.Endpoint][h.Method] = struct{}{}
-			mut.Unlock()
-		}
+	w.log.Info().Str("config-name", config.Src).Msg("running...")
+
+	// waits till disconnection from client, then removes this config's route and, if it was
+	// the last one on the listener, shuts the listener down.
+	<-config.StopCh
+	w.log.Info().Str("config-key", config.Src).Msg("stopping the configuration...")
+	registry.unregisterRoute(h.Port, h.Endpoint, h.Method)
 
-		w.log.Info().Str("config-name", config.Src).Msg("running...")
-		wg.Wait()
-	}
 	return nil
 }
 
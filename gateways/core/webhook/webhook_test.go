@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCloudEventStructuredRoundTrip(t *testing.T) {
+	ce := &cloudEvent{
+		SpecVersion:     cloudEventsSpecVersion,
+		ID:              "abc-123",
+		Source:          "/gateways/default/webhook",
+		Type:            "webhook.POST",
+		Time:            time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		DataContentType: "application/json",
+		Subject:         "my-config",
+		Data:            json.RawMessage(`{"hello":"world"}`),
+		Extensions:      map[string]string{"eventschema": "v1"},
+	}
+
+	data, err := ce.MarshalJSON()
+	if err != nil {
+		t.Fatalf("unexpected error marshaling: %v", err)
+	}
+
+	var decoded cloudEvent
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("unexpected error unmarshaling: %v", err)
+	}
+
+	if decoded.SpecVersion != ce.SpecVersion || decoded.ID != ce.ID || decoded.Source != ce.Source ||
+		decoded.Type != ce.Type || !decoded.Time.Equal(ce.Time) || decoded.DataContentType != ce.DataContentType ||
+		decoded.Subject != ce.Subject {
+		t.Fatalf("expected decoded envelope to match original, got %+v", decoded)
+	}
+	if string(decoded.Data) != string(ce.Data) {
+		t.Fatalf("expected data %s, got %s", ce.Data, decoded.Data)
+	}
+	if decoded.Extensions["eventschema"] != "v1" {
+		t.Fatalf("expected extension 'eventschema' to round-trip, got %+v", decoded.Extensions)
+	}
+}
+
+func TestCloudEventUnmarshalJSONExtensions(t *testing.T) {
+	body := []byte(`{"specversion":"1.0","id":"1","source":"/src","type":"t","time":"2020-01-02T03:04:05Z","myext":"value","count":"3"}`)
+
+	var ce cloudEvent
+	if err := json.Unmarshal(body, &ce); err != nil {
+		t.Fatalf("unexpected error unmarshaling: %v", err)
+	}
+
+	if ce.Extensions["myext"] != "value" {
+		t.Fatalf("expected extension 'myext' to be captured, got %+v", ce.Extensions)
+	}
+	if ce.Extensions["count"] != "3" {
+		t.Fatalf("expected extension 'count' to be captured, got %+v", ce.Extensions)
+	}
+}
+
+func TestCloudEventMarshalBinary(t *testing.T) {
+	ce := &cloudEvent{
+		SpecVersion: cloudEventsSpecVersion,
+		ID:          "abc-123",
+		Source:      "/gateways/default/webhook",
+		Type:        "webhook.POST",
+		Time:        time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		Subject:     "my-config",
+		Data:        json.RawMessage(`{"a":1}`),
+		Extensions:  map[string]string{"eventschema": "v1"},
+	}
+
+	data, err := ce.MarshalBinary()
+	if err != nil {
+		t.Fatalf("unexpected error marshaling binary: %v", err)
+	}
+
+	var attrs map[string]interface{}
+	if err := json.Unmarshal(data, &attrs); err != nil {
+		t.Fatalf("unexpected error unmarshaling binary attrs: %v", err)
+	}
+	if attrs["ce-specversion"] != cloudEventsSpecVersion {
+		t.Fatalf("expected ce-specversion %s, got %v", cloudEventsSpecVersion, attrs["ce-specversion"])
+	}
+	if attrs["ce-eventschema"] != "v1" {
+		t.Fatalf("expected extension to be prefixed as ce-eventschema, got %+v", attrs)
+	}
+}
+
+func TestDecodeIncomingCloudEventStructured(t *testing.T) {
+	body := []byte(`{"specversion":"1.0","id":"1","source":"/src","type":"t","time":"2020-01-02T03:04:05Z"}`)
+	req := httptest.NewRequest(http.MethodPost, "/events", nil)
+	req.Header.Set("Content-Type", cloudEventsContentType)
+
+	ce, err := decodeIncomingCloudEvent(req, body)
+	if err != nil {
+		t.Fatalf("unexpected error decoding structured CloudEvent: %v", err)
+	}
+	if ce.ID != "1" || ce.Source != "/src" || ce.Type != "t" {
+		t.Fatalf("expected decoded attributes from body, got %+v", ce)
+	}
+}
+
+func TestDecodeIncomingCloudEventBinaryWithExtensions(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/events", nil)
+	req.Header.Set("Ce-Specversion", "1.0")
+	req.Header.Set("Ce-Id", "42")
+	req.Header.Set("Ce-Source", "/upstream")
+	req.Header.Set("Ce-Type", "upstream.event")
+	req.Header.Set("Ce-Subject", "subj")
+	req.Header.Set("Ce-Time", "2020-01-02T03:04:05Z")
+	req.Header.Set("Ce-Eventschema", "v1")
+	req.Header.Set("Content-Type", "application/json")
+
+	if !isBinaryCloudEvent(req) {
+		t.Fatalf("expected request with Ce-Specversion header to be detected as binary CloudEvent")
+	}
+
+	body := []byte(`{"a":1}`)
+	ce, err := decodeIncomingCloudEvent(req, body)
+	if err != nil {
+		t.Fatalf("unexpected error decoding binary CloudEvent: %v", err)
+	}
+	if ce.ID != "42" || ce.Source != "/upstream" || ce.Type != "upstream.event" || ce.Subject != "subj" {
+		t.Fatalf("expected named attributes decoded from headers, got %+v", ce)
+	}
+	if !ce.Time.Equal(time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)) {
+		t.Fatalf("expected Ce-Time header to be parsed, got %v", ce.Time)
+	}
+	if ce.Extensions["eventschema"] != "v1" {
+		t.Fatalf("expected Ce-Eventschema header to be captured as extension, got %+v", ce.Extensions)
+	}
+	if string(ce.Data) != string(body) {
+		t.Fatalf("expected raw body to be carried as Data, got %s", ce.Data)
+	}
+}
+
+func TestEncodeStructuredVsBinary(t *testing.T) {
+	ce := &cloudEvent{SpecVersion: cloudEventsSpecVersion, ID: "1", Source: "/src", Type: "t", Time: time.Now().UTC()}
+
+	structured, err := encode(&hook{}, ce)
+	if err != nil {
+		t.Fatalf("unexpected error encoding structured: %v", err)
+	}
+	var asMap map[string]interface{}
+	if err := json.Unmarshal(structured, &asMap); err != nil {
+		t.Fatalf("expected structured encoding to be valid JSON: %v", err)
+	}
+	if _, ok := asMap["ce-specversion"]; ok {
+		t.Fatalf("expected structured encoding to not use ce- prefixed keys, got %+v", asMap)
+	}
+
+	binary, err := encode(&hook{EventFormat: eventFormatBinary}, ce)
+	if err != nil {
+		t.Fatalf("unexpected error encoding binary: %v", err)
+	}
+	if err := json.Unmarshal(binary, &asMap); err != nil {
+		t.Fatalf("expected binary encoding to be valid JSON: %v", err)
+	}
+	if _, ok := asMap["ce-specversion"]; !ok {
+		t.Fatalf("expected binary encoding to use ce- prefixed keys, got %+v", asMap)
+	}
+}
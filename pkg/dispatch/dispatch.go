@@ -0,0 +1,87 @@
+/*
+Copyright 2018 BlackRock, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package dispatch abstracts the transport a gateway uses to hand events off to sensors, so
+// the transformer sidecar isn't hard-wired to plain HTTP. A Dispatcher is constructed from the
+// connection settings in the gateway's ConfigMap and is handed one event payload per sensor
+// routing decision; whenever that ConfigMap changes, the transformer closes the current
+// Dispatcher and constructs a new one.
+package dispatch
+
+import "fmt"
+
+// Dispatcher sends an event payload to the sensor(s) configured for a gateway, using whatever
+// transport that Dispatcher implementation wraps.
+type Dispatcher interface {
+	// Dispatch delivers payload to sensor, where sensor is the sensor name. The implementation
+	// looks it up in Config.SensorRoutingKeys to get the routing target in the terms the
+	// underlying transport understands (HTTP URL, NATS subject, Kafka topic, MQTT topic), and
+	// returns an error for a sensor with no routing key.
+	Dispatch(sensor string, payload []byte) error
+
+	// Close releases any connections the Dispatcher holds open.
+	Close() error
+}
+
+// Config carries the broker connection settings needed to construct any Dispatcher
+// implementation. Only the fields relevant to the selected DispatchMechanism are populated;
+// the rest are left at their zero value.
+type Config struct {
+	// Addresses are the broker addresses (NATS servers, Kafka brokers, MQTT broker URL).
+	Addresses []string
+
+	// CredentialsSecretName/CredentialsSecretKey locate the Kubernetes secret holding broker
+	// credentials, if the broker requires auth. pkg/dispatch has no Kubernetes client of its own
+	// and does not read these fields; the caller (the transformer's
+	// ConfigMap watch) resolves the secret and populates Username/Password before calling New.
+	CredentialsSecretName string
+	CredentialsSecretKey  string
+
+	// Username/Password are the broker credentials, already resolved from the Kubernetes secret
+	// named by CredentialsSecretName/CredentialsSecretKey. Left empty for brokers that don't
+	// require auth. The secret value is expected to be either a bare token/password, or
+	// "username:password" for brokers that need both.
+	Username string
+	Password string
+
+	// SensorRoutingKeys maps a sensor name to the transport-specific routing key to dispatch to
+	// it with (NATS subject, Kafka topic, MQTT topic).
+	SensorRoutingKeys map[string]string
+
+	// PartitionKey, when set, is used to pick the Kafka partition an event lands on.
+	PartitionKey string
+
+	// QoS is the MQTT quality-of-service level to publish with.
+	QoS byte
+}
+
+// New constructs the Dispatcher for the given mechanism name ("HTTPGateway", "NATSGateway",
+// "KafkaGateway", "MQTTGateway"), matching the DispatchMechanism values validated by the
+// gateway controller.
+func New(mechanism string, config *Config) (Dispatcher, error) {
+	switch mechanism {
+	case "HTTPGateway":
+		return NewHTTPDispatcher(config), nil
+	case "NATSGateway":
+		return NewNATSDispatcher(config)
+	case "KafkaGateway":
+		return NewKafkaDispatcher(config)
+	case "MQTTGateway":
+		return NewMQTTDispatcher(config)
+	default:
+		return nil, fmt.Errorf("unknown dispatch mechanism '%s'", mechanism)
+	}
+}
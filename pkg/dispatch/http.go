@@ -0,0 +1,42 @@
+package dispatch
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+)
+
+// httpDispatcher is the default Dispatcher, preserving the gateway's original behavior of
+// posting the event payload directly to a sensor's HTTP endpoint.
+type httpDispatcher struct {
+	client *http.Client
+	routes map[string]string
+}
+
+// NewHTTPDispatcher builds a Dispatcher that POSTs events to the URL configured for each sensor.
+func NewHTTPDispatcher(config *Config) Dispatcher {
+	return &httpDispatcher{
+		client: http.DefaultClient,
+		routes: config.SensorRoutingKeys,
+	}
+}
+
+func (d *httpDispatcher) Dispatch(sensor string, payload []byte) error {
+	url, ok := d.routes[sensor]
+	if !ok {
+		return fmt.Errorf("no HTTP endpoint configured for sensor '%s'", sensor)
+	}
+	resp, err := d.client.Post(url, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("sensor '%s' returned status %d", sensor, resp.StatusCode)
+	}
+	return nil
+}
+
+func (d *httpDispatcher) Close() error {
+	return nil
+}
@@ -0,0 +1,60 @@
+package dispatch
+
+import (
+	"fmt"
+
+	"github.com/Shopify/sarama"
+)
+
+// kafkaDispatcher publishes events to a Kafka topic per sensor, keyed by Config.PartitionKey
+// (the gateway's EventSource unless the EventBus sets a partition key) so all events from the
+// same source land on the same partition.
+type kafkaDispatcher struct {
+	producer     sarama.SyncProducer
+	routes       map[string]string
+	partitionKey string
+}
+
+// NewKafkaDispatcher connects a synchronous producer to the configured brokers and returns a
+// Dispatcher that publishes to the topic configured for each sensor.
+func NewKafkaDispatcher(config *Config) (Dispatcher, error) {
+	if len(config.Addresses) == 0 {
+		return nil, fmt.Errorf("at least one Kafka broker address must be configured")
+	}
+	cfg := sarama.NewConfig()
+	cfg.Producer.Return.Successes = true
+	if config.Username != "" {
+		cfg.Net.SASL.Enable = true
+		cfg.Net.SASL.User = config.Username
+		cfg.Net.SASL.Password = config.Password
+	}
+	producer, err := sarama.NewSyncProducer(config.Addresses, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to Kafka: %w", err)
+	}
+	return &kafkaDispatcher{
+		producer:     producer,
+		routes:       config.SensorRoutingKeys,
+		partitionKey: config.PartitionKey,
+	}, nil
+}
+
+func (d *kafkaDispatcher) Dispatch(sensor string, payload []byte) error {
+	topic, ok := d.routes[sensor]
+	if !ok {
+		return fmt.Errorf("no Kafka topic configured for sensor '%s'", sensor)
+	}
+	msg := &sarama.ProducerMessage{
+		Topic: topic,
+		Value: sarama.ByteEncoder(payload),
+	}
+	if d.partitionKey != "" {
+		msg.Key = sarama.StringEncoder(d.partitionKey)
+	}
+	_, _, err := d.producer.SendMessage(msg)
+	return err
+}
+
+func (d *kafkaDispatcher) Close() error {
+	return d.producer.Close()
+}
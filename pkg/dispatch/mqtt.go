@@ -0,0 +1,50 @@
+package dispatch
+
+import (
+	"fmt"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// mqttDispatcher publishes events to an MQTT topic per sensor at a fixed QoS level.
+type mqttDispatcher struct {
+	client mqtt.Client
+	routes map[string]string
+	qos    byte
+}
+
+// NewMQTTDispatcher connects to the configured MQTT broker and returns a Dispatcher that
+// publishes to the topic configured for each sensor at the gateway's configured QoS.
+func NewMQTTDispatcher(config *Config) (Dispatcher, error) {
+	if len(config.Addresses) == 0 {
+		return nil, fmt.Errorf("an MQTT broker address must be configured")
+	}
+	opts := mqtt.NewClientOptions()
+	for _, address := range config.Addresses {
+		opts.AddBroker(address)
+	}
+	if config.Username != "" {
+		opts.SetUsername(config.Username)
+		opts.SetPassword(config.Password)
+	}
+	client := mqtt.NewClient(opts)
+	if token := client.Connect(); token.Wait() && token.Error() != nil {
+		return nil, fmt.Errorf("failed to connect to MQTT broker: %w", token.Error())
+	}
+	return &mqttDispatcher{client: client, routes: config.SensorRoutingKeys, qos: config.QoS}, nil
+}
+
+func (d *mqttDispatcher) Dispatch(sensor string, payload []byte) error {
+	topic, ok := d.routes[sensor]
+	if !ok {
+		return fmt.Errorf("no MQTT topic configured for sensor '%s'", sensor)
+	}
+	token := d.client.Publish(topic, d.qos, false, payload)
+	token.Wait()
+	return token.Error()
+}
+
+func (d *mqttDispatcher) Close() error {
+	d.client.Disconnect(250)
+	return nil
+}
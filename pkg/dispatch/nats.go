@@ -0,0 +1,46 @@
+package dispatch
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/nats-io/nats.go"
+)
+
+// natsDispatcher publishes events to a NATS subject per sensor.
+type natsDispatcher struct {
+	conn   *nats.Conn
+	routes map[string]string
+}
+
+// NewNATSDispatcher connects to the configured NATS servers and returns a Dispatcher that
+// publishes to the subject configured for each sensor.
+func NewNATSDispatcher(config *Config) (Dispatcher, error) {
+	if len(config.Addresses) == 0 {
+		return nil, fmt.Errorf("at least one NATS server address must be configured")
+	}
+	opts := []nats.Option{nats.MaxReconnects(-1)}
+	if config.Username != "" {
+		opts = append(opts, nats.UserInfo(config.Username, config.Password))
+	} else if config.Password != "" {
+		opts = append(opts, nats.Token(config.Password))
+	}
+	conn, err := nats.Connect(strings.Join(config.Addresses, ","), opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to NATS: %w", err)
+	}
+	return &natsDispatcher{conn: conn, routes: config.SensorRoutingKeys}, nil
+}
+
+func (d *natsDispatcher) Dispatch(sensor string, payload []byte) error {
+	subject, ok := d.routes[sensor]
+	if !ok {
+		return fmt.Errorf("no NATS subject configured for sensor '%s'", sensor)
+	}
+	return d.conn.Publish(subject, payload)
+}
+
+func (d *natsDispatcher) Close() error {
+	d.conn.Close()
+	return nil
+}